@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plugins defines the interface bare-metal provisioning backends
+// (e.g. Tinkerbell) must implement to be driven by the baremetal cloud
+// provider.
+package plugins
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Server is the common representation of a provisioned bare-metal server
+// returned by a PluginDriver.
+type Server interface {
+	// Name returns the name of the underlying server resource.
+	Name() string
+}
+
+// Day2Driver is implemented by PluginDrivers that support post-provisioning
+// ("Day 2") configuration actions against an already-provisioned server,
+// such as installing packages or tuning the kernel without recreating it.
+type Day2Driver interface {
+	// ApplyConfig instantiates the named config template against the
+	// server backing the given Machine, substituting values into it.
+	ApplyConfig(ctx context.Context, meta metav1.ObjectMeta, configName string, values map[string]string) (Server, error)
+	// RollbackConfig reverts the most recent application of the named
+	// config template.
+	RollbackConfig(ctx context.Context, meta metav1.ObjectMeta, configName string) error
+}
+
+// PluginDriver is implemented by each bare-metal provisioning backend and is
+// invoked by the baremetal cloud provider to reconcile Machine objects.
+type PluginDriver interface {
+	// GetServer returns the current state of the server backing the given
+	// Machine, if one exists.
+	GetServer(ctx context.Context, meta metav1.ObjectMeta, rawConfig runtime.RawExtension) (Server, error)
+	// ProvisionServer claims (and, if necessary, provisions) a server for
+	// the given Machine.
+	ProvisionServer(ctx context.Context, meta metav1.ObjectMeta, rawConfig runtime.RawExtension, userdata string) (Server, error)
+	// DeprovisionServer releases the server backing the given Machine.
+	DeprovisionServer(ctx context.Context, meta metav1.ObjectMeta) error
+	// Validate checks the plugin-specific provider config for errors.
+	Validate(rawConfig runtime.RawExtension) error
+}
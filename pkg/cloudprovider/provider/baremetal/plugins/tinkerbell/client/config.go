@@ -0,0 +1,265 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	tinktypes "github.com/kubermatic/machine-controller/pkg/cloudprovider/provider/baremetal/plugins/tinkerbell/types"
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// configHistoryConfigMapName holds the Day-2 config application history for
+// every piece of Hardware machine-controller has configured, keyed by
+// hardware UID.
+const configHistoryConfigMapName = "machine-controller-config-history"
+
+// ConfigClient materializes Day-2 Workflows from config templates and
+// tracks the version history of configs applied to a piece of Hardware.
+type ConfigClient struct {
+	TinkClient ctrlruntimeclient.Client
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewConfigClient returns a ConfigClient backed by the given Tinkerbell
+// cluster client.
+func NewConfigClient(tinkClient ctrlruntimeclient.Client) *ConfigClient {
+	return &ConfigClient{TinkClient: tinkClient, locks: map[string]*sync.Mutex{}}
+}
+
+// lockFor serializes ApplyConfig/RollbackConfig calls for the same
+// hardwareUID within this process. It is keyed on hardwareUID alone, not
+// hardwareUID+configName, because saveHistory reads and rewrites the whole
+// per-hardware history blob (every configName at once); locking per
+// configName would still let two different configNames for the same
+// hardware race saveHistory's read-modify-write and silently drop one
+// another's record. Without this lock, two concurrent calls could read the
+// same history snapshot and the loser's Workflow would end up live but
+// untracked once saveHistory overwrote the winner's entry. The returned
+// func releases the lock.
+func (c *ConfigClient) lockFor(hardwareUID string) func() {
+	c.mu.Lock()
+	l, ok := c.locks[hardwareUID]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[hardwareUID] = l
+	}
+	c.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// ApplyConfig creates a new Workflow that runs template against hardware,
+// substituting values into it, and records the application in that
+// hardware's config history.
+func (c *ConfigClient) ApplyConfig(ctx context.Context, hardwareUID string, hardware *tinkv1alpha1.Hardware, configName string, template *tinkv1alpha1.Template, values map[string]string) (*tinkv1alpha1.Workflow, error) {
+	unlock := c.lockFor(hardwareUID)
+	defer unlock()
+
+	history, err := c.History(ctx, hardwareUID)
+	if err != nil {
+		return nil, err
+	}
+
+	version := 1
+	for _, record := range history {
+		if record.ConfigName == configName && record.Version >= version {
+			version = record.Version + 1
+		}
+	}
+
+	wfName := fmt.Sprintf("%s-%s-v%d", hardware.Name, configName, version)
+
+	instanceTemplate, err := c.renderConfigTemplate(ctx, wfName, hardware.Namespace, template, values)
+	if err != nil {
+		return nil, err
+	}
+
+	workflow := &tinkv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wfName,
+			Namespace: hardware.Namespace,
+		},
+		Spec: tinkv1alpha1.WorkflowSpec{
+			TemplateRef: instanceTemplate.Name,
+			HardwareRef: hardware.Name,
+		},
+	}
+	if err := c.TinkClient.Create(ctx, workflow); err != nil {
+		return nil, fmt.Errorf("failed to create config workflow %s: %w", wfName, err)
+	}
+
+	record := tinktypes.ConfigRecord{
+		ConfigName:   configName,
+		Version:      version,
+		WorkflowName: wfName,
+		Values:       values,
+		AppliedAt:    metav1.Now(),
+	}
+	if err := c.saveHistory(ctx, hardwareUID, append(history, record)); err != nil {
+		return nil, err
+	}
+
+	return workflow, nil
+}
+
+// renderConfigTemplate substitutes values into template's Go-template body
+// (the same mechanism TemplateOverride uses, see
+// tinktypes.RenderGoTemplate) and creates the result as a new Template
+// named name, so each config application runs with its own parameters
+// instead of all instances sharing the literal config template.
+func (c *ConfigClient) renderConfigTemplate(ctx context.Context, name, namespace string, template *tinkv1alpha1.Template, values map[string]string) (*tinkv1alpha1.Template, error) {
+	var raw string
+	if template.Spec.Data != nil {
+		raw = *template.Spec.Data
+	}
+
+	rendered, err := tinktypes.RenderGoTemplate(name, raw, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render config template %s: %w", template.Name, err)
+	}
+
+	instance := &tinkv1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: tinkv1alpha1.TemplateSpec{
+			Data: &rendered,
+		},
+	}
+	if err := c.TinkClient.Create(ctx, instance); err != nil {
+		return nil, fmt.Errorf("failed to create config template %s: %w", name, err)
+	}
+	return instance, nil
+}
+
+// RollbackConfig deletes the most recently applied Workflow for configName,
+// along with the per-instance Template it ran, and removes it from the
+// hardware's config history.
+func (c *ConfigClient) RollbackConfig(ctx context.Context, hardwareUID, namespace, configName string) error {
+	unlock := c.lockFor(hardwareUID)
+	defer unlock()
+
+	history, err := c.History(ctx, hardwareUID)
+	if err != nil {
+		return err
+	}
+
+	latest := -1
+	for i, record := range history {
+		if record.ConfigName == configName && (latest == -1 || record.Version > history[latest].Version) {
+			latest = i
+		}
+	}
+	if latest == -1 {
+		return fmt.Errorf("no applied config %q found for hardware %s", configName, hardwareUID)
+	}
+
+	workflow := &tinkv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: history[latest].WorkflowName, Namespace: namespace},
+	}
+	if err := c.TinkClient.Delete(ctx, workflow); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete config workflow %s: %w", history[latest].WorkflowName, err)
+	}
+
+	// renderConfigTemplate names the per-instance Template after the
+	// Workflow it backs, so the same name deletes it.
+	instanceTemplate := &tinkv1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: history[latest].WorkflowName, Namespace: namespace},
+	}
+	if err := c.TinkClient.Delete(ctx, instanceTemplate); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete config template %s: %w", history[latest].WorkflowName, err)
+	}
+
+	history = append(history[:latest], history[latest+1:]...)
+	return c.saveHistory(ctx, hardwareUID, history)
+}
+
+// History returns the config application history recorded for hardwareUID,
+// oldest first.
+func (c *ConfigClient) History(ctx context.Context, hardwareUID string) ([]tinktypes.ConfigRecord, error) {
+	cm := &corev1.ConfigMap{}
+	ref := ctrlruntimeclient.ObjectKey{Name: configHistoryConfigMapName, Namespace: tinkStackNamespace}
+	if err := c.TinkClient.Get(ctx, ref, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get config history: %w", err)
+	}
+
+	raw, ok := cm.Data[hardwareUID]
+	if !ok {
+		return nil, nil
+	}
+
+	var history []tinktypes.ConfigRecord
+	if err := json.Unmarshal([]byte(raw), &history); err != nil {
+		return nil, fmt.Errorf("failed to decode config history for %s: %w", hardwareUID, err)
+	}
+	return history, nil
+}
+
+// saveHistory writes history into the shared configHistoryConfigMapName
+// ConfigMap under hardwareUID. The ConfigMap is shared across every piece
+// of Hardware, so a plain Get/mutate/Update would lose another hardware's
+// concurrent write; retry.RetryOnConflict re-reads and re-applies the
+// mutation on each conflict, up to the attempt limit of retry.DefaultRetry.
+func (c *ConfigClient) saveHistory(ctx context.Context, hardwareUID string, history []tinktypes.ConfigRecord) error {
+	encoded, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("failed to encode config history for %s: %w", hardwareUID, err)
+	}
+
+	ref := ctrlruntimeclient.ObjectKey{Name: configHistoryConfigMapName, Namespace: tinkStackNamespace}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := c.TinkClient.Get(ctx, ref, cm)
+		switch {
+		case apierrors.IsNotFound(err):
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: configHistoryConfigMapName, Namespace: tinkStackNamespace},
+				Data:       map[string]string{hardwareUID: string(encoded)},
+			}
+			return c.TinkClient.Create(ctx, cm)
+		case err != nil:
+			return fmt.Errorf("failed to get config history: %w", err)
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[hardwareUID] = string(encoded)
+		return c.TinkClient.Update(ctx, cm)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save config history for %s: %w", hardwareUID, err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConfigTemplateClient reads reusable Day-2 config templates: ordinary
+// tinkv1alpha1.Template objects in the tink-stack namespace that describe a
+// repeatable post-provisioning action (package install, kernel tunables,
+// disk repartitioning, ...) rather than an initial OS install.
+type ConfigTemplateClient struct {
+	TinkClient ctrlruntimeclient.Client
+}
+
+// NewConfigTemplateClient returns a ConfigTemplateClient backed by the given
+// Tinkerbell cluster client.
+func NewConfigTemplateClient(tinkClient ctrlruntimeclient.Client) *ConfigTemplateClient {
+	return &ConfigTemplateClient{TinkClient: tinkClient}
+}
+
+// GetConfigTemplate fetches the named config template from tink-stack.
+func (c *ConfigTemplateClient) GetConfigTemplate(ctx context.Context, name string) (*tinkv1alpha1.Template, error) {
+	template := &tinkv1alpha1.Template{}
+	ref := ctrlruntimeclient.ObjectKey{Name: name, Namespace: tinkStackNamespace}
+	if err := c.TinkClient.Get(ctx, ref, template); err != nil {
+		return nil, fmt.Errorf("failed to get config template %s: %w", name, err)
+	}
+	return template, nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add corev1 to scheme: %v", err)
+	}
+	if err := tinkv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add tinkv1alpha1 to scheme: %v", err)
+	}
+	return scheme
+}
+
+func TestConfigClientApplyConfigVersionsAndSubstitution(t *testing.T) {
+	scheme := newTestScheme(t)
+	tinkClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	configClient := NewConfigClient(tinkClient)
+
+	hardware := &tinkv1alpha1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Namespace: "default"}}
+	rawTemplate := "env: {{.env}}"
+	configTemplate := &tinkv1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap"},
+		Spec:       tinkv1alpha1.TemplateSpec{Data: &rawTemplate},
+	}
+
+	workflow, err := configClient.ApplyConfig(context.Background(), "hw-uid-1", hardware, "bootstrap", configTemplate, map[string]string{"env": "staging"})
+	if err != nil {
+		t.Fatalf("ApplyConfig() first call: unexpected error: %v", err)
+	}
+	if workflow.Name != "worker-1-bootstrap-v1" {
+		t.Errorf("expected workflow name worker-1-bootstrap-v1, got %q", workflow.Name)
+	}
+
+	instance := &tinkv1alpha1.Template{}
+	ref := ctrlruntimeclient.ObjectKey{Name: workflow.Spec.TemplateRef, Namespace: hardware.Namespace}
+	if err := tinkClient.Get(context.Background(), ref, instance); err != nil {
+		t.Fatalf("failed to get rendered config template: %v", err)
+	}
+	if instance.Spec.Data == nil || !strings.Contains(*instance.Spec.Data, "staging") {
+		t.Errorf("expected rendered template to contain substituted value, got %v", instance.Spec.Data)
+	}
+
+	// A second application of the same config should bump the version.
+	workflow2, err := configClient.ApplyConfig(context.Background(), "hw-uid-1", hardware, "bootstrap", configTemplate, map[string]string{"env": "production"})
+	if err != nil {
+		t.Fatalf("ApplyConfig() second call: unexpected error: %v", err)
+	}
+	if workflow2.Name != "worker-1-bootstrap-v2" {
+		t.Errorf("expected workflow name worker-1-bootstrap-v2, got %q", workflow2.Name)
+	}
+
+	history, err := configClient.History(context.Background(), "hw-uid-1")
+	if err != nil {
+		t.Fatalf("History(): unexpected error: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 history records, got %d", len(history))
+	}
+	if history[0].Version != 1 || history[1].Version != 2 {
+		t.Errorf("expected versions 1 and 2, got %d and %d", history[0].Version, history[1].Version)
+	}
+}
+
+func TestConfigClientRollbackConfig(t *testing.T) {
+	scheme := newTestScheme(t)
+	tinkClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	configClient := NewConfigClient(tinkClient)
+
+	hardware := &tinkv1alpha1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Namespace: "default"}}
+	rawTemplate := "env: {{.env}}"
+	configTemplate := &tinkv1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap"},
+		Spec:       tinkv1alpha1.TemplateSpec{Data: &rawTemplate},
+	}
+
+	if _, err := configClient.ApplyConfig(context.Background(), "hw-uid-1", hardware, "bootstrap", configTemplate, map[string]string{"env": "staging"}); err != nil {
+		t.Fatalf("ApplyConfig(): unexpected error: %v", err)
+	}
+
+	if err := configClient.RollbackConfig(context.Background(), "hw-uid-1", hardware.Namespace, "bootstrap"); err != nil {
+		t.Fatalf("RollbackConfig(): unexpected error: %v", err)
+	}
+
+	history, err := configClient.History(context.Background(), "hw-uid-1")
+	if err != nil {
+		t.Fatalf("History(): unexpected error: %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("expected rollback to remove the history record, got %d entries", len(history))
+	}
+
+	if err := configClient.RollbackConfig(context.Background(), "hw-uid-1", hardware.Namespace, "bootstrap"); err == nil {
+		t.Error("expected rolling back an already-rolled-back config to return an error")
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client wraps the Tinkerbell CRDs (Hardware, Template, Workflow)
+// behind small, purpose-built clients used by the Tinkerbell driver.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tinktypes "github.com/kubermatic/machine-controller/pkg/cloudprovider/provider/baremetal/plugins/tinkerbell/types"
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hardwareIDAnnotation records the UID of the Machine a Hardware object is
+// currently provisioned for.
+const hardwareIDAnnotation = "machine-controller.kubermatic.io/hardware-id"
+
+// Capacity predicates in a HardwareSelector are matched against these
+// annotations, which are expected to be set on Hardware objects out of
+// band (e.g. by whatever inventories the physical machines).
+const (
+	cpuAnnotation        = "machine-controller.kubermatic.io/cpu"
+	memoryGBAnnotation   = "machine-controller.kubermatic.io/memory-gb"
+	diskCountAnnotation  = "machine-controller.kubermatic.io/disk-count"
+	diskSizeGBAnnotation = "machine-controller.kubermatic.io/disk-size-gb"
+)
+
+// ErrNoHardwareAvailable is returned by ClaimHardware when no free Hardware
+// object matches the given selector.
+var ErrNoHardwareAvailable = errors.New("no hardware available matching selector")
+
+// workflowRetryCountAnnotation tracks how many times ProvisionServer has
+// deleted and recreated a failed or stuck Workflow for this Hardware, so a
+// configured WorkflowRetryPolicy.MaxRetries can be enforced across
+// reconciles.
+const workflowRetryCountAnnotation = "machine-controller.kubermatic.io/workflow-retry-count"
+
+// HardwareClient manages the lifecycle of tinkv1alpha1.Hardware objects on
+// behalf of the Tinkerbell driver. It talks to two clusters: the one hosting
+// the Machine CRs (KubeClient) and the one hosting the Tinkerbell stack
+// (TinkClient).
+type HardwareClient struct {
+	KubeClient ctrlruntimeclient.Client
+	TinkClient ctrlruntimeclient.Client
+}
+
+// NewHardwareClient returns a HardwareClient backed by the given clients.
+func NewHardwareClient(kubeClient, tinkClient ctrlruntimeclient.Client) *HardwareClient {
+	return &HardwareClient{
+		KubeClient: kubeClient,
+		TinkClient: tinkClient,
+	}
+}
+
+// GetHardware fetches the Hardware object referenced by name/namespace.
+func (c *HardwareClient) GetHardware(ctx context.Context, ref types.NamespacedName) (*tinkv1alpha1.Hardware, error) {
+	hardware := &tinkv1alpha1.Hardware{}
+	if err := c.KubeClient.Get(ctx, ref, hardware); err != nil {
+		return nil, fmt.Errorf("failed to get hardware %s: %w", ref, err)
+	}
+	return hardware, nil
+}
+
+// GetHardwareWithID returns the Hardware object currently claimed by the
+// Machine with the given UID, if any.
+func (c *HardwareClient) GetHardwareWithID(ctx context.Context, id string) (*tinkv1alpha1.Hardware, error) {
+	list := &tinkv1alpha1.HardwareList{}
+	if err := c.KubeClient.List(ctx, list); err != nil {
+		return nil, fmt.Errorf("failed to list hardware: %w", err)
+	}
+
+	for i := range list.Items {
+		if list.Items[i].Annotations[hardwareIDAnnotation] == id {
+			return &list.Items[i], nil
+		}
+	}
+
+	return nil, apierrors.NewNotFound(tinkv1alpha1.Resource("hardware"), id)
+}
+
+// SetHardwareID tags the given Hardware object as owned by the Machine with
+// the given UID. An empty id clears the ownership.
+func (c *HardwareClient) SetHardwareID(ctx context.Context, hardware *tinkv1alpha1.Hardware, id string) error {
+	if hardware.Annotations == nil {
+		hardware.Annotations = map[string]string{}
+	}
+	if id == "" {
+		delete(hardware.Annotations, hardwareIDAnnotation)
+	} else {
+		hardware.Annotations[hardwareIDAnnotation] = id
+	}
+	if err := c.KubeClient.Update(ctx, hardware); err != nil {
+		return fmt.Errorf("failed to set hardware id on %s: %w", hardware.Name, err)
+	}
+	return nil
+}
+
+// SetHardwareUserData stores the rendered cloud-init user-data on the
+// Hardware object so Hegel can serve it to the booting machine.
+func (c *HardwareClient) SetHardwareUserData(ctx context.Context, hardware *tinkv1alpha1.Hardware, userdata string) error {
+	if hardware.Spec.UserData == nil {
+		hardware.Spec.UserData = &userdata
+	} else {
+		*hardware.Spec.UserData = userdata
+	}
+	if err := c.KubeClient.Update(ctx, hardware); err != nil {
+		return fmt.Errorf("failed to set user-data on %s: %w", hardware.Name, err)
+	}
+	return nil
+}
+
+// WorkflowRetryCount returns how many times the current Workflow for
+// hardware has been deleted and recreated by ProvisionServer.
+func (c *HardwareClient) WorkflowRetryCount(hardware *tinkv1alpha1.Hardware) int {
+	return intAnnotation(hardware, workflowRetryCountAnnotation)
+}
+
+// SetWorkflowRetryCount records count as the number of times the current
+// Workflow for hardware has been retried, so it survives across reconciles.
+func (c *HardwareClient) SetWorkflowRetryCount(ctx context.Context, hardware *tinkv1alpha1.Hardware, count int) error {
+	if hardware.Annotations == nil {
+		hardware.Annotations = map[string]string{}
+	}
+	hardware.Annotations[workflowRetryCountAnnotation] = strconv.Itoa(count)
+	if err := c.KubeClient.Update(ctx, hardware); err != nil {
+		return fmt.Errorf("failed to set workflow retry count on %s: %w", hardware.Name, err)
+	}
+	return nil
+}
+
+// CreateHardwareOnTinkCluster mirrors the Hardware object into the cluster
+// running the Tinkerbell stack, creating it if it doesn't already exist.
+func (c *HardwareClient) CreateHardwareOnTinkCluster(ctx context.Context, hardware *tinkv1alpha1.Hardware) error {
+	existing := &tinkv1alpha1.Hardware{}
+	err := c.TinkClient.Get(ctx, types.NamespacedName{Name: hardware.Name, Namespace: hardware.Namespace}, existing)
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get hardware %s on tink cluster: %w", hardware.Name, err)
+	}
+
+	toCreate := hardware.DeepCopy()
+	toCreate.ResourceVersion = ""
+	if err := c.TinkClient.Create(ctx, toCreate); err != nil {
+		return fmt.Errorf("failed to create hardware %s on tink cluster: %w", hardware.Name, err)
+	}
+	return nil
+}
+
+// ClaimHardware finds a Hardware object matching selector that no Machine
+// currently owns and atomically claims it for machineUID, turning the
+// selected Hardware objects into a pool rather than a single pre-assigned
+// box. Candidates are tried in list order; each claim attempt carries the
+// candidate's resourceVersion as a precondition, so a candidate another
+// Machine claimed concurrently is skipped in favour of the next one. If no
+// candidate can be claimed, it returns ErrNoHardwareAvailable.
+func (c *HardwareClient) ClaimHardware(ctx context.Context, selector tinktypes.HardwareSelector, machineUID string) (*tinkv1alpha1.Hardware, error) {
+	labelSelector, err := metav1.LabelSelectorAsSelector(&selector.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hardware selector: %w", err)
+	}
+
+	list := &tinkv1alpha1.HardwareList{}
+	if err := c.KubeClient.List(ctx, list, ctrlruntimeclient.MatchingLabelsSelector{Selector: labelSelector}); err != nil {
+		return nil, fmt.Errorf("failed to list hardware: %w", err)
+	}
+
+	for i := range list.Items {
+		candidate := &list.Items[i]
+		if candidate.Annotations[hardwareIDAnnotation] != "" {
+			continue // already claimed
+		}
+		if !matchesCapacity(candidate, selector) {
+			continue
+		}
+
+		if candidate.Annotations == nil {
+			candidate.Annotations = map[string]string{}
+		}
+		candidate.Annotations[hardwareIDAnnotation] = machineUID
+
+		if err := c.KubeClient.Update(ctx, candidate); err != nil {
+			if apierrors.IsConflict(err) {
+				// Another Machine claimed it first; try the next one.
+				continue
+			}
+			return nil, fmt.Errorf("failed to claim hardware %s: %w", candidate.Name, err)
+		}
+
+		return candidate, nil
+	}
+
+	return nil, ErrNoHardwareAvailable
+}
+
+func matchesCapacity(hw *tinkv1alpha1.Hardware, selector tinktypes.HardwareSelector) bool {
+	if selector.MinCPU > 0 && intAnnotation(hw, cpuAnnotation) < selector.MinCPU {
+		return false
+	}
+	if selector.MinMemoryGB > 0 && intAnnotation(hw, memoryGBAnnotation) < selector.MinMemoryGB {
+		return false
+	}
+	if selector.MinDiskCount > 0 && intAnnotation(hw, diskCountAnnotation) < selector.MinDiskCount {
+		return false
+	}
+	if selector.MinDiskSizeGB > 0 && intAnnotation(hw, diskSizeGBAnnotation) < selector.MinDiskSizeGB {
+		return false
+	}
+	if selector.NICMACPrefix != "" && !hasMACPrefix(hw, selector.NICMACPrefix) {
+		return false
+	}
+	return true
+}
+
+func intAnnotation(hw *tinkv1alpha1.Hardware, key string) int {
+	v, err := strconv.Atoi(hw.Annotations[key])
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+func hasMACPrefix(hw *tinkv1alpha1.Hardware, prefix string) bool {
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP != nil && strings.HasPrefix(iface.DHCP.MAC, prefix) {
+			return true
+		}
+	}
+	return false
+}
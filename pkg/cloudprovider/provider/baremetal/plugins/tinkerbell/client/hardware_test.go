@@ -0,0 +1,233 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tinktypes "github.com/kubermatic/machine-controller/pkg/cloudprovider/provider/baremetal/plugins/tinkerbell/types"
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMatchesCapacity(t *testing.T) {
+	hardware := func(annotations map[string]string, macs ...string) *tinkv1alpha1.Hardware {
+		hw := &tinkv1alpha1.Hardware{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+		for _, mac := range macs {
+			hw.Spec.Interfaces = append(hw.Spec.Interfaces, tinkv1alpha1.Interface{
+				DHCP: &tinkv1alpha1.DHCP{MAC: mac},
+			})
+		}
+		return hw
+	}
+
+	fullAnnotations := map[string]string{
+		cpuAnnotation:        "8",
+		memoryGBAnnotation:   "32",
+		diskCountAnnotation:  "2",
+		diskSizeGBAnnotation: "512",
+	}
+
+	tests := []struct {
+		name     string
+		hw       *tinkv1alpha1.Hardware
+		selector tinktypes.HardwareSelector
+		want     bool
+	}{
+		{
+			name:     "no predicates always matches",
+			hw:       hardware(nil),
+			selector: tinktypes.HardwareSelector{},
+			want:     true,
+		},
+		{
+			name:     "meets all predicates",
+			hw:       hardware(fullAnnotations, "aa:bb:cc:00:00:01"),
+			selector: tinktypes.HardwareSelector{MinCPU: 8, MinMemoryGB: 32, MinDiskCount: 2, MinDiskSizeGB: 512, NICMACPrefix: "aa:bb:cc"},
+			want:     true,
+		},
+		{
+			name:     "insufficient cpu",
+			hw:       hardware(fullAnnotations),
+			selector: tinktypes.HardwareSelector{MinCPU: 16},
+			want:     false,
+		},
+		{
+			name:     "insufficient memory",
+			hw:       hardware(fullAnnotations),
+			selector: tinktypes.HardwareSelector{MinMemoryGB: 64},
+			want:     false,
+		},
+		{
+			name:     "insufficient disk count",
+			hw:       hardware(fullAnnotations),
+			selector: tinktypes.HardwareSelector{MinDiskCount: 4},
+			want:     false,
+		},
+		{
+			name:     "insufficient disk size",
+			hw:       hardware(fullAnnotations),
+			selector: tinktypes.HardwareSelector{MinDiskSizeGB: 1024},
+			want:     false,
+		},
+		{
+			name:     "missing annotation treated as zero",
+			hw:       hardware(nil),
+			selector: tinktypes.HardwareSelector{MinCPU: 1},
+			want:     false,
+		},
+		{
+			name:     "mac prefix mismatch",
+			hw:       hardware(fullAnnotations, "11:22:33:00:00:01"),
+			selector: tinktypes.HardwareSelector{NICMACPrefix: "aa:bb:cc"},
+			want:     false,
+		},
+		{
+			name:     "mac prefix matches one of several interfaces",
+			hw:       hardware(fullAnnotations, "11:22:33:00:00:01", "aa:bb:cc:00:00:02"),
+			selector: tinktypes.HardwareSelector{NICMACPrefix: "aa:bb:cc"},
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesCapacity(tt.hw, tt.selector); got != tt.want {
+				t.Errorf("matchesCapacity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasMACPrefix(t *testing.T) {
+	hw := &tinkv1alpha1.Hardware{
+		Spec: tinkv1alpha1.HardwareSpec{
+			Interfaces: []tinkv1alpha1.Interface{
+				{DHCP: &tinkv1alpha1.DHCP{MAC: "aa:bb:cc:00:00:01"}},
+				{DHCP: nil},
+			},
+		},
+	}
+
+	if !hasMACPrefix(hw, "aa:bb:cc") {
+		t.Error("expected hardware to match prefix aa:bb:cc")
+	}
+	if hasMACPrefix(hw, "11:22:33") {
+		t.Error("expected hardware not to match prefix 11:22:33")
+	}
+}
+
+func poolHardware(name string, annotations map[string]string) *tinkv1alpha1.Hardware {
+	return &tinkv1alpha1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Labels:      map[string]string{"pool": "gpu"},
+			Annotations: annotations,
+		},
+	}
+}
+
+var poolSelector = tinktypes.HardwareSelector{
+	LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"pool": "gpu"}},
+}
+
+func TestClaimHardwareSuccess(t *testing.T) {
+	scheme := newTestScheme(t)
+	hw := poolHardware("hw-1", nil)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+	hwClient := NewHardwareClient(kubeClient, kubeClient)
+
+	claimed, err := hwClient.ClaimHardware(context.Background(), poolSelector, "machine-uid-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claimed.Name != "hw-1" {
+		t.Fatalf("expected hw-1 to be claimed, got %q", claimed.Name)
+	}
+	if claimed.Annotations[hardwareIDAnnotation] != "machine-uid-1" {
+		t.Errorf("expected hardware-id annotation to be set, got %q", claimed.Annotations[hardwareIDAnnotation])
+	}
+}
+
+func TestClaimHardwareSkipsAlreadyClaimed(t *testing.T) {
+	scheme := newTestScheme(t)
+	claimedHW := poolHardware("hw-1", map[string]string{hardwareIDAnnotation: "other-machine"})
+	freeHW := poolHardware("hw-2", nil)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(claimedHW, freeHW).Build()
+	hwClient := NewHardwareClient(kubeClient, kubeClient)
+
+	claimed, err := hwClient.ClaimHardware(context.Background(), poolSelector, "machine-uid-2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claimed.Name != "hw-2" {
+		t.Fatalf("expected the free candidate hw-2 to be claimed, got %q", claimed.Name)
+	}
+}
+
+func TestClaimHardwareFallsThroughOnConflict(t *testing.T) {
+	scheme := newTestScheme(t)
+	hw1 := poolHardware("hw-1", nil)
+	hw2 := poolHardware("hw-2", nil)
+	kubeClient := &onceConflictingClient{
+		Client:       fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw1, hw2).Build(),
+		conflictOnce: map[string]bool{"hw-1": true},
+	}
+	hwClient := NewHardwareClient(kubeClient, kubeClient)
+
+	claimed, err := hwClient.ClaimHardware(context.Background(), poolSelector, "machine-uid-3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claimed.Name != "hw-2" {
+		t.Fatalf("expected claim to fall through to hw-2 after a conflict on hw-1, got %q", claimed.Name)
+	}
+}
+
+func TestClaimHardwareNoneAvailable(t *testing.T) {
+	scheme := newTestScheme(t)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	hwClient := NewHardwareClient(kubeClient, kubeClient)
+
+	_, err := hwClient.ClaimHardware(context.Background(), poolSelector, "machine-uid-4")
+	if !errors.Is(err, ErrNoHardwareAvailable) {
+		t.Fatalf("expected ErrNoHardwareAvailable, got %v", err)
+	}
+}
+
+// onceConflictingClient wraps a ctrlruntimeclient.Client and fails the
+// first Update for each name listed in conflictOnce with an IsConflict
+// error, to exercise ClaimHardware's fall-through-on-conflict path
+// deterministically.
+type onceConflictingClient struct {
+	ctrlruntimeclient.Client
+	conflictOnce map[string]bool
+}
+
+func (c *onceConflictingClient) Update(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.UpdateOption) error {
+	if c.conflictOnce[obj.GetName()] {
+		delete(c.conflictOnce, obj.GetName())
+		return apierrors.NewConflict(tinkv1alpha1.Resource("hardware"), obj.GetName(), errors.New("concurrent update"))
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tinkStackNamespace is where machine-controller creates the Templates and
+// Workflows it owns.
+const tinkStackNamespace = "tink-stack"
+
+// TemplateClient manages the tinkv1alpha1.Template objects that machine-
+// controller generates for machines that don't bring their own.
+type TemplateClient struct {
+	TinkClient ctrlruntimeclient.Client
+}
+
+// NewTemplateClient returns a TemplateClient backed by the given Tinkerbell
+// cluster client.
+func NewTemplateClient(tinkClient ctrlruntimeclient.Client) *TemplateClient {
+	return &TemplateClient{TinkClient: tinkClient}
+}
+
+// CreateTemplate renders the built-in provisioning workflow template for
+// osImageURL/hegelURL and creates it under tmplNamespacedName.
+func (c *TemplateClient) CreateTemplate(ctx context.Context, tmplNamespacedName types.NamespacedName, osImageURL, hegelURL string) (*tinkv1alpha1.Template, error) {
+	data := defaultTemplateData(tmplNamespacedName.Name, osImageURL, hegelURL)
+
+	template := &tinkv1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tmplNamespacedName.Name,
+			Namespace: tmplNamespacedName.Namespace,
+		},
+		Spec: tinkv1alpha1.TemplateSpec{
+			Data: &data,
+		},
+	}
+
+	if err := c.TinkClient.Create(ctx, template); err != nil {
+		return nil, fmt.Errorf("failed to create template %s: %w", tmplNamespacedName, err)
+	}
+	return template, nil
+}
+
+// Delete removes the Template at ref. A missing Template is not treated as
+// an error.
+func (c *TemplateClient) Delete(ctx context.Context, ref types.NamespacedName) error {
+	template := &tinkv1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		},
+	}
+	if err := c.TinkClient.Delete(ctx, template); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete template %s: %w", ref, err)
+	}
+	return nil
+}
+
+// defaultTemplateData builds the YAML of the hard-coded provisioning
+// workflow machine-controller has always generated for Tinkerbell machines.
+func defaultTemplateData(name, osImageURL, hegelURL string) string {
+	return fmt.Sprintf(`version: "0.1"
+name: %s
+global_timeout: 1800
+tasks:
+  - name: "os-installation"
+    worker: "{{.device_1}}"
+    actions:
+      - name: "stream-image"
+        image: image2disk:latest
+        timeout: 600
+        environment:
+          IMG_URL: %s
+          DEST_DISK: /dev/sda
+      - name: "configure-metadata"
+        image: cexec:latest
+        timeout: 90
+        environment:
+          HEGEL_URL: %s
+`, name, osImageURL, hegelURL)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	tinktypes "github.com/kubermatic/machine-controller/pkg/cloudprovider/provider/baremetal/plugins/tinkerbell/types"
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WorkflowClient creates and removes tinkv1alpha1.Workflow objects that
+// drive the provisioning of a single piece of Hardware.
+type WorkflowClient struct {
+	TinkClient ctrlruntimeclient.Client
+}
+
+// NewWorkflowClient returns a WorkflowClient backed by the given Tinkerbell
+// cluster client.
+func NewWorkflowClient(tinkClient ctrlruntimeclient.Client) *WorkflowClient {
+	return &WorkflowClient{TinkClient: tinkClient}
+}
+
+// CreateWorkflow creates a Workflow that runs templateName against the
+// given Hardware, unless one already exists for it.
+func (c *WorkflowClient) CreateWorkflow(ctx context.Context, name, templateName string, hardware tinktypes.Hardware) error {
+	workflow := &tinkv1alpha1.Workflow{}
+	err := c.TinkClient.Get(ctx, ctrlruntimeclient.ObjectKey{Name: name, Namespace: hardware.Namespace}, workflow)
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get workflow %s: %w", name, err)
+	}
+
+	workflow = &tinkv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: hardware.Namespace,
+		},
+		Spec: tinkv1alpha1.WorkflowSpec{
+			TemplateRef: templateName,
+			HardwareRef: hardware.Name,
+		},
+	}
+
+	if err := c.TinkClient.Create(ctx, workflow); err != nil {
+		return fmt.Errorf("failed to create workflow %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetWorkflowStatus returns the ProvisioningStatus of the named Workflow. It
+// returns a nil status, with no error, if the Workflow doesn't exist yet.
+func (c *WorkflowClient) GetWorkflowStatus(ctx context.Context, name, namespace string) (*tinktypes.ProvisioningStatus, error) {
+	workflow := &tinkv1alpha1.Workflow{}
+	err := c.TinkClient.Get(ctx, ctrlruntimeclient.ObjectKey{Name: name, Namespace: namespace}, workflow)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow %s: %w", name, err)
+	}
+
+	status := &tinktypes.ProvisioningStatus{
+		WorkflowName: workflow.Name,
+		State:        string(workflow.Status.State),
+	}
+
+	for _, task := range workflow.Status.Tasks {
+		for _, action := range task.Actions {
+			status.Actions = append(status.Actions, tinktypes.ActionStatus{
+				TaskName:  task.Name,
+				Name:      action.Name,
+				State:     string(action.Status),
+				StartedAt: action.StartedAt,
+				Message:   action.Message,
+			})
+		}
+	}
+
+	return status, nil
+}
+
+// DeleteWorkflow deletes the named Workflow. A missing Workflow is not
+// treated as an error.
+func (c *WorkflowClient) DeleteWorkflow(ctx context.Context, name, namespace string) error {
+	workflow := &tinkv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+	if err := c.TinkClient.Delete(ctx, workflow); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete workflow %s: %w", name, err)
+	}
+	return nil
+}
@@ -19,6 +19,7 @@ package tinkerbell
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 
 	providerconfigtypes "github.com/kubermatic/machine-controller/pkg/providerconfig/types"
@@ -28,7 +29,6 @@ import (
 	metadataclient "github.com/kubermatic/machine-controller/pkg/cloudprovider/provider/baremetal/plugins/tinkerbell/metadata"
 	tinktypes "github.com/kubermatic/machine-controller/pkg/cloudprovider/provider/baremetal/plugins/tinkerbell/types"
 	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -39,17 +39,32 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 )
 
+// forceReprovisionAnnotation, when present on the Machine, makes
+// ProvisionServer delete any existing Workflow and start a fresh one,
+// regardless of its current state.
+const forceReprovisionAnnotation = "machine-controller.kubermatic.io/force-reprovision"
+
+// workflowName returns the name of the Workflow that provisions hardwareName.
+func workflowName(hardwareName string) string {
+	return hardwareName + "-workflow"
+}
+
 type driver struct {
-	ClusterName    string
-	OSImageURL     string
-	HegelURL       string
-	TinkClient     ctrlruntimeclient.Client
-	KubeClient     ctrlruntimeclient.Client
-	HardwareRef    types.NamespacedName
-	MetadataClient metadataclient.Client
-	HardwareClient client.HardwareClient
-	WorkflowClient client.WorkflowClient
-	TemplateClient client.TemplateClient
+	ClusterName          string
+	OSImageURL           string
+	HegelURL             string
+	TinkClient           ctrlruntimeclient.WithWatch
+	KubeClient           ctrlruntimeclient.Client
+	HardwareRef          types.NamespacedName
+	HardwareSelector     *tinktypes.HardwareSelector
+	TemplateOverride     tinktypes.TemplateOverride
+	WorkflowRetryPolicy  tinktypes.WorkflowRetryPolicy
+	MetadataClient       metadataclient.Client
+	HardwareClient       client.HardwareClient
+	WorkflowClient       client.WorkflowClient
+	TemplateClient       client.TemplateClient
+	ConfigTemplateClient client.ConfigTemplateClient
+	ConfigClient         client.ConfigClient
 }
 
 func init() {
@@ -61,7 +76,7 @@ func init() {
 
 // NewTinkerbellDriver returns a new TinkerBell driver with a configured tinkserver address and a client timeout.
 func NewTinkerbellDriver(mdConfig *metadataclient.Config, tinkConfig tinktypes.Config, tinkSpec *tinktypes.TinkerbellPluginSpec) (plugins.PluginDriver, error) {
-	tinkClient, err := ctrlruntimeclient.New(tinkConfig.RestConfig, ctrlruntimeclient.Options{Scheme: scheme.Scheme})
+	tinkClient, err := ctrlruntimeclient.NewWithWatch(tinkConfig.RestConfig, ctrlruntimeclient.Options{Scheme: scheme.Scheme})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create k8s client: %w", err)
 	}
@@ -77,29 +92,54 @@ func NewTinkerbellDriver(mdConfig *metadataclient.Config, tinkConfig tinktypes.C
 		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
 	}
 
-	mdClient, err := metadataclient.NewMetadataClient(mdConfig)
+	if tinkSpec.MetadataServer.Enabled {
+		mdConfig.Server = &metadataclient.ServerConfig{
+			Enabled:       true,
+			ListenAddress: tinkSpec.MetadataServer.ListenAddress.Value,
+			TLSCertFile:   tinkSpec.MetadataServer.TLSCertFile.Value,
+			TLSKeyFile:    tinkSpec.MetadataServer.TLSKeyFile.Value,
+			TinkClient:    tinkClient,
+		}
+	}
 
+	mdClient, err := metadataclient.NewMetadataClient(mdConfig)
 	if err != nil {
 		return nil, err
 	}
 
+	hegelURL := tinkSpec.HegelURL.Value
+	if tinkSpec.MetadataServer.Enabled {
+		// Booting machines are pointed at our own embedded metadata
+		// server rather than a separately deployed Hegel.
+		hegelURL = mdClient.URL()
+	}
+
 	hwClient := client.NewHardwareClient(k8sClient, tinkClient)
 
 	wkClient := client.NewWorkflowClient(tinkClient)
 
 	tmplClient := client.NewTemplateClient(tinkClient)
 
+	configTmplClient := client.NewConfigTemplateClient(tinkClient)
+
+	configClient := client.NewConfigClient(tinkClient)
+
 	d := driver{
-		ClusterName:    tinkSpec.ClusterName.Value,
-		TinkClient:     tinkClient,
-		HardwareRef:    tinkSpec.HardwareRef,
-		KubeClient:     k8sClient,
-		MetadataClient: mdClient,
-		HardwareClient: *hwClient,
-		WorkflowClient: *wkClient,
-		TemplateClient: *tmplClient,
-		OSImageURL:     tinkSpec.OSImageURL.Value,
-		HegelURL:       tinkSpec.HegelURL.Value,
+		ClusterName:          tinkSpec.ClusterName.Value,
+		TinkClient:           tinkClient,
+		HardwareRef:          tinkSpec.HardwareRef,
+		HardwareSelector:     tinkSpec.HardwareSelector,
+		TemplateOverride:     tinkSpec.TemplateOverride,
+		WorkflowRetryPolicy:  tinkSpec.WorkflowRetryPolicy,
+		KubeClient:           k8sClient,
+		MetadataClient:       mdClient,
+		HardwareClient:       *hwClient,
+		WorkflowClient:       *wkClient,
+		TemplateClient:       *tmplClient,
+		ConfigTemplateClient: *configTmplClient,
+		ConfigClient:         *configClient,
+		OSImageURL:           tinkSpec.OSImageURL.Value,
+		HegelURL:             hegelURL,
 	}
 
 	return &d, nil
@@ -111,18 +151,44 @@ func (d *driver) GetServer(ctx context.Context, meta metav1.ObjectMeta, _ runtim
 	if err != nil {
 		return nil, err
 	}
-	server := tinktypes.Hardware{Hardware: targetHardware}
+
+	status, err := d.WorkflowClient.GetWorkflowStatus(ctx, workflowName(targetHardware.Name), targetHardware.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	configHistory, err := d.ConfigClient.History(ctx, string(meta.UID))
+	if err != nil {
+		return nil, err
+	}
+
+	server := tinktypes.Hardware{Hardware: targetHardware, ProvisioningStatus: status, ConfigHistory: configHistory}
 	return &server, nil
 }
 
-func (d *driver) ProvisionServer(ctx context.Context, meta metav1.ObjectMeta, _ runtime.RawExtension, userdata string) (plugins.Server, error) {
+// acquireHardware returns the Hardware object to provision meta onto. If
+// HardwareSelector is configured, it claims a free matching Hardware object
+// out of the pool; otherwise it falls back to the single, statically
+// configured HardwareRef.
+func (d *driver) acquireHardware(ctx context.Context, meta metav1.ObjectMeta) (*tinkv1alpha1.Hardware, error) {
+	if d.HardwareSelector != nil {
+		return d.HardwareClient.ClaimHardware(ctx, *d.HardwareSelector, string(meta.UID))
+	}
+
 	hardware, err := d.HardwareClient.GetHardware(ctx, d.HardwareRef)
 	if err != nil {
 		return nil, err
 	}
 
-	err = d.HardwareClient.SetHardwareID(ctx, hardware, string(meta.UID))
+	if err := d.HardwareClient.SetHardwareID(ctx, hardware, string(meta.UID)); err != nil {
+		return nil, err
+	}
+
+	return hardware, nil
+}
 
+func (d *driver) ProvisionServer(ctx context.Context, meta metav1.ObjectMeta, _ runtime.RawExtension, userdata string) (plugins.Server, error) {
+	hardware, err := d.acquireHardware(ctx, meta)
 	if err != nil {
 		return nil, err
 	}
@@ -139,29 +205,76 @@ func (d *driver) ProvisionServer(ctx context.Context, meta metav1.ObjectMeta, _
 		return nil, err
 	}
 
-	template := &tinkv1alpha1.Template{}
+	template, err := d.resolveTemplate(ctx, meta, hardware)
+	if err != nil {
+		return nil, err
+	}
+	server := tinktypes.Hardware{Hardware: hardware}
+
+	wfName := workflowName(server.Name)
+
+	status, err := d.WorkflowClient.GetWorkflowStatus(ctx, wfName, hardware.Namespace)
+	if err != nil {
+		return nil, err
+	}
 
-	tmplNamespacedName := types.NamespacedName{Name: meta.Name, Namespace: "tink-stack"}
-	if err := d.TinkClient.Get(ctx, tmplNamespacedName, template); err != nil {
-		if !apierrors.IsNotFound(err) {
-			return nil, fmt.Errorf("failed to get template: %w", err)
+	_, forceReprovision := meta.Annotations[forceReprovisionAnnotation]
+	// A workflow is "stuck" either because it finished in STATE_FAILED or
+	// because its current action has been running longer than
+	// WorkflowRetryPolicy.Timeout allows (e.g. a worker that never checked
+	// in); either way it can't bring the machine up on its own.
+	stuck := status.Failed() || status.Stale(d.WorkflowRetryPolicy.Timeout.Duration)
+
+	switch {
+	case forceReprovision || stuck:
+		retries := d.HardwareClient.WorkflowRetryCount(hardware)
+		if !forceReprovision && d.WorkflowRetryPolicy.MaxRetries > 0 && retries >= d.WorkflowRetryPolicy.MaxRetries {
+			return nil, fmt.Errorf("workflow %s for hardware %s did not succeed after %d retries", wfName, hardware.Name, retries)
 		}
-		// Create template if not exists
-		template, err = d.TemplateClient.CreateTemplate(ctx, tmplNamespacedName, d.OSImageURL, d.HegelURL)
-		if err != nil {
+
+		// Delete the existing Workflow so the CreateWorkflow call below
+		// starts a fresh one.
+		if err := d.WorkflowClient.DeleteWorkflow(ctx, wfName, hardware.Namespace); err != nil {
+			return nil, err
+		}
+
+		nextRetries := retries + 1
+		if forceReprovision {
+			// An explicit user request isn't counted against the retry budget.
+			nextRetries = 0
+		}
+		if err := d.HardwareClient.SetWorkflowRetryCount(ctx, hardware, nextRetries); err != nil {
+			return nil, err
+		}
+
+	case status != nil && status.State == tinktypes.StateSuccess && d.HardwareClient.WorkflowRetryCount(hardware) != 0:
+		// The workflow succeeded; forget earlier retries so a later,
+		// unrelated failure starts counting from zero again.
+		if err := d.HardwareClient.SetWorkflowRetryCount(ctx, hardware, 0); err != nil {
 			return nil, err
 		}
 	}
-	server := tinktypes.Hardware{Hardware: hardware}
 
-	err = d.WorkflowClient.CreateWorkflow(ctx, server.Name, template.Name, server)
+	err = d.WorkflowClient.CreateWorkflow(ctx, wfName, template.Name, server)
 	if err != nil {
 		return nil, err
 	}
 	return &server, nil
 }
 
-func (d *driver) Validate(_ runtime.RawExtension) error {
+func (d *driver) Validate(rawConfig runtime.RawExtension) error {
+	spec := tinktypes.TinkerbellPluginSpec{}
+	if len(rawConfig.Raw) > 0 {
+		if err := json.Unmarshal(rawConfig.Raw, &spec); err != nil {
+			return fmt.Errorf("failed to unmarshal tinkerbell provider config: %w", err)
+		}
+	}
+
+	if spec.TemplateOverride.Raw != "" {
+		if _, err := renderTemplateOverride(spec.TemplateOverride.Raw, tinktypes.TemplateData{}); err != nil {
+			return fmt.Errorf("invalid templateOverride: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -174,14 +287,20 @@ func (d *driver) DeprovisionServer(ctx context.Context, meta metav1.ObjectMeta)
 	}
 
 	// Step 3: Delete the associated Workflow
-	workflowName := targetHardware.Name + "-workflow" // Assuming workflow names are derived from hardware names
-	if err := d.WorkflowClient.DeleteWorkflow(ctx, workflowName, targetHardware.Namespace); err != nil {
-		return fmt.Errorf("failed to delete workflow %s: %w", workflowName, err)
+	wfName := workflowName(targetHardware.Name)
+	if err := d.WorkflowClient.DeleteWorkflow(ctx, wfName, targetHardware.Namespace); err != nil {
+		return fmt.Errorf("failed to delete workflow %s: %w", wfName, err)
 	}
 
-	// Step 4: Delete the Hardware
-	if err := d.TinkClient.Delete(ctx, targetHardware); err != nil {
-		return fmt.Errorf("failed to delete hardware %s: %w", targetHardware.Name, err)
+	// Step 4: Release the Hardware. Pool hardware (HardwareSelector) is
+	// pre-registered physical inventory shared across Machines, so it is
+	// only unclaimed, never deleted, leaving it free for the next Machine
+	// to claim. Statically-referenced hardware (HardwareRef) has always
+	// been exclusively owned by this Machine and is deleted as before.
+	if d.HardwareSelector == nil {
+		if err := d.TinkClient.Delete(ctx, targetHardware); err != nil {
+			return fmt.Errorf("failed to delete hardware %s: %w", targetHardware.Name, err)
+		}
 	}
 
 	// Step 5: Reset the hardware ID in the machine-controller cluster
@@ -189,14 +308,52 @@ func (d *driver) DeprovisionServer(ctx context.Context, meta metav1.ObjectMeta)
 		return fmt.Errorf("failed to reset hardware ID for %s: %w", targetHardware.Name, err)
 	}
 
-	// Step 6: Delete the Template object
-	tmplNamespacedName := types.NamespacedName{Name: meta.Name, Namespace: "tink-stack"}
-	if err := d.TemplateClient.Delete(ctx, tmplNamespacedName); err != nil {
-		return fmt.Errorf("failed to reset hardware ID for %s: %w", targetHardware.Name, err)
+	// Step 6: Delete the Template object, unless it was supplied by the user
+	// via TemplateOverride.Ref, in which case we never owned it. A Raw
+	// override is rendered into a Template this driver created, so it is
+	// deleted like the built-in one.
+	if d.TemplateOverride.Ref == nil {
+		tmplNamespacedName := types.NamespacedName{Name: meta.Name, Namespace: "tink-stack"}
+		if err := d.TemplateClient.Delete(ctx, tmplNamespacedName); err != nil {
+			return fmt.Errorf("failed to delete template for %s: %w", targetHardware.Name, err)
+		}
 	}
 	return nil
 }
 
+// ApplyConfig instantiates the named Day-2 config template against the
+// Hardware backing meta, substituting values into it, and records the
+// application in that Hardware's config history.
+func (d *driver) ApplyConfig(ctx context.Context, meta metav1.ObjectMeta, configName string, values map[string]string) (plugins.Server, error) {
+	targetHardware, err := d.HardwareClient.GetHardwareWithID(ctx, string(meta.UID))
+	if err != nil {
+		return nil, err
+	}
+
+	configTemplate, err := d.ConfigTemplateClient.GetConfigTemplate(ctx, configName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.ConfigClient.ApplyConfig(ctx, string(meta.UID), targetHardware, configName, configTemplate, values); err != nil {
+		return nil, err
+	}
+
+	server := tinktypes.Hardware{Hardware: targetHardware}
+	return &server, nil
+}
+
+// RollbackConfig reverts the most recent application of the named Day-2
+// config template against the Hardware backing meta.
+func (d *driver) RollbackConfig(ctx context.Context, meta metav1.ObjectMeta, configName string) error {
+	targetHardware, err := d.HardwareClient.GetHardwareWithID(ctx, string(meta.UID))
+	if err != nil {
+		return err
+	}
+
+	return d.ConfigClient.RollbackConfig(ctx, string(meta.UID), targetHardware.Namespace, configName)
+}
+
 func GetConfig(driverConfig tinktypes.TinkerbellPluginSpec, aa func(configVar providerconfigtypes.ConfigVarString, envVarName string) (string, error)) (*tinktypes.Config, error) {
 	config := tinktypes.Config{}
 	var err error
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metadataclient talks to the metadata service (Hegel) that the
+// Tinkerbell driver relies on to hand cloud-init user-data and instance
+// metadata to booting machines. It can also run that service itself: see
+// Server.
+package metadataclient
+
+// Config configures the metadata client used by the Tinkerbell driver.
+type Config struct {
+	// Endpoint is the base URL of the Hegel-compatible metadata service.
+	// Ignored if Server is non-nil and enabled.
+	Endpoint string
+
+	// Server, if set and enabled, makes NewMetadataClient start an
+	// embedded metadata server instead of pointing at Endpoint.
+	Server *ServerConfig
+}
+
+// Client is implemented by anything able to serve the metadata the
+// Tinkerbell driver's booted machines read during provisioning.
+type Client interface {
+	// URL returns the base URL machines should be pointed at to fetch their
+	// metadata.
+	URL() string
+}
+
+type client struct {
+	endpoint string
+}
+
+// NewMetadataClient returns a Client for the metadata service described by
+// cfg. If cfg.Server is enabled, it starts the embedded metadata server and
+// returns a Client pointed at it; otherwise it returns a Client pointed at
+// cfg.Endpoint, assuming an externally deployed Hegel.
+func NewMetadataClient(cfg *Config) (Client, error) {
+	if cfg.Server != nil && cfg.Server.Enabled {
+		return NewServer(*cfg.Server)
+	}
+
+	return &client{endpoint: cfg.Endpoint}, nil
+}
+
+func (c *client) URL() string {
+	return c.endpoint
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadataclient
+
+import (
+	"context"
+	"sync"
+
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/watch"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hardwareCache is an in-memory, watch-refreshed copy of every Hardware
+// object the driver manages, indexed by the IP address a booting machine is
+// expected to request its metadata from. It exists so the metadata server
+// can answer requests without a Get per request.
+type hardwareCache struct {
+	mu   sync.RWMutex
+	byIP map[string]*tinkv1alpha1.Hardware
+}
+
+func newHardwareCache() *hardwareCache {
+	return &hardwareCache{byIP: map[string]*tinkv1alpha1.Hardware{}}
+}
+
+func (c *hardwareCache) lookup(ip string) (*tinkv1alpha1.Hardware, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	hw, ok := c.byIP[ip]
+	return hw, ok
+}
+
+func (c *hardwareCache) set(hw *tinkv1alpha1.Hardware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ip := range hardwareIPs(hw) {
+		c.byIP[ip] = hw
+	}
+}
+
+func (c *hardwareCache) remove(hw *tinkv1alpha1.Hardware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ip := range hardwareIPs(hw) {
+		delete(c.byIP, ip)
+	}
+}
+
+func hardwareIPs(hw *tinkv1alpha1.Hardware) []string {
+	var ips []string
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP != nil && iface.DHCP.IP != nil && iface.DHCP.IP.Address != "" {
+			ips = append(ips, iface.DHCP.IP.Address)
+		}
+	}
+	return ips
+}
+
+// run seeds the cache with a List and then keeps it fresh by watching for
+// changes until ctx is cancelled.
+func (c *hardwareCache) run(ctx context.Context, tinkClient ctrlruntimeclient.WithWatch) error {
+	list := &tinkv1alpha1.HardwareList{}
+	if err := tinkClient.List(ctx, list); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		c.set(&list.Items[i])
+	}
+
+	watcher, err := tinkClient.Watch(ctx, &tinkv1alpha1.HardwareList{})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer watcher.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					return
+				}
+				hw, ok := event.Object.(*tinkv1alpha1.Hardware)
+				if !ok {
+					continue
+				}
+				if event.Type == watch.Deleted {
+					c.remove(hw)
+				} else {
+					c.set(hw)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
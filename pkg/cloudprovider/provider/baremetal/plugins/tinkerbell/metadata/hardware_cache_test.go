@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadataclient
+
+import (
+	"testing"
+
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func hardwareWithIP(name, ip string) *tinkv1alpha1.Hardware {
+	hw := &tinkv1alpha1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	hw.Spec.Interfaces = []tinkv1alpha1.Interface{
+		{DHCP: &tinkv1alpha1.DHCP{IP: &tinkv1alpha1.IP{Address: ip}}},
+	}
+	return hw
+}
+
+func TestHardwareCacheSetLookupRemove(t *testing.T) {
+	cache := newHardwareCache()
+
+	if _, ok := cache.lookup("10.0.0.5"); ok {
+		t.Fatal("expected empty cache to have no entry")
+	}
+
+	hw := hardwareWithIP("worker-1", "10.0.0.5")
+	cache.set(hw)
+
+	got, ok := cache.lookup("10.0.0.5")
+	if !ok || got.Name != "worker-1" {
+		t.Fatalf("expected to find worker-1 at 10.0.0.5, got %v, ok=%v", got, ok)
+	}
+
+	cache.remove(hw)
+	if _, ok := cache.lookup("10.0.0.5"); ok {
+		t.Fatal("expected entry to be gone after remove")
+	}
+}
+
+func TestHardwareCacheSetOverwritesPreviousOwner(t *testing.T) {
+	cache := newHardwareCache()
+
+	cache.set(hardwareWithIP("worker-1", "10.0.0.5"))
+	cache.set(hardwareWithIP("worker-2", "10.0.0.5"))
+
+	got, ok := cache.lookup("10.0.0.5")
+	if !ok || got.Name != "worker-2" {
+		t.Fatalf("expected worker-2 to own 10.0.0.5 after re-set, got %v, ok=%v", got, ok)
+	}
+}
+
+func TestHardwareCacheSetWithoutInterfacesIsNoop(t *testing.T) {
+	cache := newHardwareCache()
+	hw := &tinkv1alpha1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}}
+
+	cache.set(hw)
+	cache.remove(hw)
+}
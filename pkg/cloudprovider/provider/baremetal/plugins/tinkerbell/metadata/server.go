@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadataclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ServerConfig configures the embedded Hegel-compatible metadata server.
+type ServerConfig struct {
+	Enabled       bool
+	ListenAddress string
+	TLSCertFile   string
+	TLSKeyFile    string
+
+	// TinkClient is used to watch Hardware objects so the server can
+	// answer requests from its in-memory cache. Required if Enabled.
+	TinkClient ctrlruntimeclient.WithWatch
+}
+
+// Server serves EC2-style instance metadata (/2009-04-04/meta-data/*,
+// /user-data) and Hegel's /metadata JSON endpoint, backed by the Hardware
+// objects the Tinkerbell driver already manages, so users don't need a
+// separately deployed Hegel to boot Tinkerbell workers.
+type Server struct {
+	cfg   ServerConfig
+	cache *hardwareCache
+}
+
+// NewServer starts the embedded metadata server described by cfg and
+// returns a handle to it. The server keeps running for the lifetime of the
+// process.
+func NewServer(cfg ServerConfig) (*Server, error) {
+	s := &Server{cfg: cfg, cache: newHardwareCache()}
+
+	if err := s.cache.run(context.Background(), cfg.TinkClient); err != nil {
+		return nil, fmt.Errorf("failed to start hardware informer: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/2009-04-04/meta-data/", s.handleEC2MetaData)
+	mux.HandleFunc("/user-data", s.handleEC2UserData)
+	mux.HandleFunc("/metadata", s.handleHegelMetadata)
+
+	httpServer := &http.Server{Addr: cfg.ListenAddress, Handler: mux}
+
+	go func() {
+		var err error
+		if cfg.TLSCertFile != "" {
+			err = httpServer.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		// A failure here (e.g. port already in use, bad cert path) should
+		// only take down the metadata feature, not the whole process.
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("metadata server on %s stopped unexpectedly: %v", cfg.ListenAddress, err)
+		}
+	}()
+
+	return s, nil
+}
+
+// URL returns the base URL booting machines should be pointed at to fetch
+// their metadata.
+func (s *Server) URL() string {
+	scheme := "http"
+	if s.cfg.TLSCertFile != "" {
+		scheme = "https"
+	}
+	return scheme + "://" + s.cfg.ListenAddress
+}
+
+// hardwareForRequest authenticates a metadata request by matching its
+// source IP address against the Hardware objects the driver manages.
+// Intentionally IP-only, not MAC-based: see the doc comment on
+// tinktypes.MetadataServerConfig for why.
+func (s *Server) hardwareForRequest(r *http.Request) (*tinkv1alpha1.Hardware, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	hw, ok := s.cache.lookup(host)
+	if !ok {
+		return nil, fmt.Errorf("no hardware registered for source address %s", host)
+	}
+	return hw, nil
+}
+
+func (s *Server) handleEC2MetaData(w http.ResponseWriter, r *http.Request) {
+	hw, err := s.hardwareForRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	switch strings.TrimPrefix(r.URL.Path, "/2009-04-04/meta-data/") {
+	case "instance-id", "hostname", "local-hostname":
+		fmt.Fprint(w, hw.Name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleEC2UserData(w http.ResponseWriter, r *http.Request) {
+	hw, err := s.hardwareForRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if hw.Spec.UserData != nil {
+		fmt.Fprint(w, *hw.Spec.UserData)
+	}
+}
+
+func (s *Server) handleHegelMetadata(w http.ResponseWriter, r *http.Request) {
+	hw, err := s.hardwareForRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	metadata := map[string]any{
+		"instance": map[string]string{
+			"id":       string(hw.UID),
+			"hostname": hw.Name,
+		},
+	}
+	if hw.Spec.UserData != nil {
+		metadata["userdata"] = *hw.Spec.UserData
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(metadata)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadataclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestServer(hw *tinkv1alpha1.Hardware) *Server {
+	cache := newHardwareCache()
+	if hw != nil {
+		cache.set(hw)
+	}
+	return &Server{cache: cache}
+}
+
+func TestServerURL(t *testing.T) {
+	plain := &Server{cfg: ServerConfig{ListenAddress: "0.0.0.0:8080"}}
+	if got := plain.URL(); got != "http://0.0.0.0:8080" {
+		t.Errorf("expected http scheme when TLS isn't configured, got %q", got)
+	}
+
+	tls := &Server{cfg: ServerConfig{ListenAddress: "0.0.0.0:8443", TLSCertFile: "/tls/tls.crt"}}
+	if got := tls.URL(); got != "https://0.0.0.0:8443" {
+		t.Errorf("expected https scheme when TLSCertFile is set, got %q", got)
+	}
+}
+
+func TestHandleEC2MetaData(t *testing.T) {
+	hw := hardwareWithIP("worker-1", "10.0.0.5")
+	s := newTestServer(hw)
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "hostname for known hardware", path: "/2009-04-04/meta-data/hostname", remoteAddr: "10.0.0.5:12345", wantStatus: http.StatusOK, wantBody: "worker-1"},
+		{name: "unknown meta-data field", path: "/2009-04-04/meta-data/public-ipv4", remoteAddr: "10.0.0.5:12345", wantStatus: http.StatusNotFound},
+		{name: "unregistered source address", path: "/2009-04-04/meta-data/hostname", remoteAddr: "10.0.0.9:12345", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+
+			s.handleEC2MetaData(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleEC2UserData(t *testing.T) {
+	userdata := "#cloud-config\n"
+	hw := hardwareWithIP("worker-1", "10.0.0.5")
+	hw.Spec.UserData = &userdata
+	s := newTestServer(hw)
+
+	req := httptest.NewRequest(http.MethodGet, "/user-data", nil)
+	req.RemoteAddr = "10.0.0.5:12345"
+	rec := httptest.NewRecorder()
+
+	s.handleEC2UserData(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if rec.Body.String() != userdata {
+		t.Errorf("expected user-data %q, got %q", userdata, rec.Body.String())
+	}
+}
+
+func TestHandleEC2UserDataUnregisteredSource(t *testing.T) {
+	s := newTestServer(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/user-data", nil)
+	req.RemoteAddr = "10.0.0.9:12345"
+	rec := httptest.NewRecorder()
+
+	s.handleEC2UserData(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for unregistered source, got %d", rec.Code)
+	}
+}
+
+func TestHandleHegelMetadata(t *testing.T) {
+	userdata := "#cloud-config\n"
+	hw := hardwareWithIP("worker-1", "10.0.0.5")
+	hw.UID = "abc-123"
+	hw.Spec.UserData = &userdata
+	s := newTestServer(hw)
+
+	req := httptest.NewRequest(http.MethodGet, "/metadata", nil)
+	req.RemoteAddr = "10.0.0.5:12345"
+	rec := httptest.NewRecorder()
+
+	s.handleHegelMetadata(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json content type, got %q", ct)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	instance, ok := body["instance"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected an instance object in the response, got %v", body)
+	}
+	if instance["hostname"] != "worker-1" {
+		t.Errorf("expected hostname worker-1, got %v", instance["hostname"])
+	}
+	if instance["id"] != "abc-123" {
+		t.Errorf("expected id abc-123, got %v", instance["id"])
+	}
+	if got, _ := body["userdata"].(string); !strings.Contains(got, "cloud-config") {
+		t.Errorf("expected userdata to be included, got %v", body["userdata"])
+	}
+}
+
+func TestHandleHegelMetadataUnregisteredSource(t *testing.T) {
+	s := newTestServer(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/metadata", nil)
+	req.RemoteAddr = "10.0.0.9:12345"
+	rec := httptest.NewRecorder()
+
+	s.handleHegelMetadata(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for unregistered source, got %d", rec.Code)
+	}
+}
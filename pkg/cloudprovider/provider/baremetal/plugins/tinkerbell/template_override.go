@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tinkerbell
+
+import (
+	"context"
+	"fmt"
+
+	tinktypes "github.com/kubermatic/machine-controller/pkg/cloudprovider/provider/baremetal/plugins/tinkerbell/types"
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// resolveTemplate returns the Template that should drive the workflow for
+// hardware: the user-supplied override if TemplateOverride is set, otherwise
+// the built-in one machine-controller generates (creating it on first use).
+func (d *driver) resolveTemplate(ctx context.Context, meta metav1.ObjectMeta, hardware *tinkv1alpha1.Hardware) (*tinkv1alpha1.Template, error) {
+	override := d.TemplateOverride
+
+	switch {
+	case override.Ref != nil:
+		template := &tinkv1alpha1.Template{}
+		if err := d.TinkClient.Get(ctx, *override.Ref, template); err != nil {
+			return nil, fmt.Errorf("failed to get overridden template %s: %w", *override.Ref, err)
+		}
+		return template, nil
+
+	case override.Raw != "":
+		data := templateDataFromHardware(hardware, d.OSImageURL, d.HegelURL)
+		rendered, err := renderTemplateOverride(override.Raw, data)
+		if err != nil {
+			return nil, err
+		}
+		return d.ensureRenderedTemplate(ctx, meta, rendered)
+	}
+
+	template := &tinkv1alpha1.Template{}
+	tmplNamespacedName := types.NamespacedName{Name: meta.Name, Namespace: "tink-stack"}
+	if err := d.TinkClient.Get(ctx, tmplNamespacedName, template); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get template: %w", err)
+		}
+		return d.TemplateClient.CreateTemplate(ctx, tmplNamespacedName, d.OSImageURL, d.HegelURL)
+	}
+	return template, nil
+}
+
+// ensureRenderedTemplate persists rendered as the Template for meta,
+// creating it if it doesn't exist yet, analogous to the default-template
+// path in resolveTemplate. Without this, a raw TemplateOverride would only
+// ever exist in memory and the Workflow created from it would reference a
+// Template that was never actually created on the Tinkerbell cluster.
+func (d *driver) ensureRenderedTemplate(ctx context.Context, meta metav1.ObjectMeta, rendered *tinkv1alpha1.Template) (*tinkv1alpha1.Template, error) {
+	tmplNamespacedName := types.NamespacedName{Name: meta.Name, Namespace: "tink-stack"}
+
+	existing := &tinkv1alpha1.Template{}
+	err := d.TinkClient.Get(ctx, tmplNamespacedName, existing)
+	if err == nil {
+		return existing, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get template override: %w", err)
+	}
+
+	rendered.ObjectMeta = metav1.ObjectMeta{
+		Name:      tmplNamespacedName.Name,
+		Namespace: tmplNamespacedName.Namespace,
+	}
+	if err := d.TinkClient.Create(ctx, rendered); err != nil {
+		return nil, fmt.Errorf("failed to create template override: %w", err)
+	}
+	return rendered, nil
+}
+
+// renderTemplateOverride Go-templates raw against data and parses the
+// result into a Template object. It is used both to validate a
+// TemplateOverride up-front and to materialize it at provisioning time.
+func renderTemplateOverride(raw string, data tinktypes.TemplateData) (*tinkv1alpha1.Template, error) {
+	rendered, err := tinktypes.RenderGoTemplate("templateOverride", raw, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render template override: %w", err)
+	}
+
+	parsed := &tinkv1alpha1.Template{}
+	if err := yaml.Unmarshal([]byte(rendered), parsed); err != nil {
+		return nil, fmt.Errorf("template override does not parse as a Tinkerbell Template: %w", err)
+	}
+
+	return parsed, nil
+}
+
+// templateDataFromHardware resolves the values a TemplateOverride may
+// reference from the Hardware object it is being rendered for.
+func templateDataFromHardware(hardware *tinkv1alpha1.Hardware, osImageURL, hegelURL string) tinktypes.TemplateData {
+	data := tinktypes.TemplateData{
+		OSImageURL: osImageURL,
+		HegelURL:   hegelURL,
+		Hostname:   hardware.Name,
+		Disk:       "/dev/sda",
+	}
+
+	if len(hardware.Spec.Interfaces) > 0 && hardware.Spec.Interfaces[0].DHCP != nil {
+		data.DeviceIP = hardware.Spec.Interfaces[0].DHCP.IP.Address
+	}
+
+	return data
+}
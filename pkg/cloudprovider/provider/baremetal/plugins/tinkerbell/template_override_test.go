@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tinkerbell
+
+import (
+	"strings"
+	"testing"
+
+	tinktypes "github.com/kubermatic/machine-controller/pkg/cloudprovider/provider/baremetal/plugins/tinkerbell/types"
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+)
+
+func TestRenderTemplateOverride(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		data    tinktypes.TemplateData
+		wantErr bool
+		check   func(t *testing.T, tmpl *tinkv1alpha1.Template)
+	}{
+		{
+			name: "substitutes fields",
+			raw: `
+apiVersion: "tinkerbell.org/v1alpha1"
+kind: Template
+metadata:
+  name: {{.Hostname}}
+spec:
+  data: |
+    image: {{.OSImageURL}}
+    hegel: {{.HegelURL}}
+`,
+			data: tinktypes.TemplateData{Hostname: "worker-1", OSImageURL: "http://image", HegelURL: "http://hegel"},
+			check: func(t *testing.T, tmpl *tinkv1alpha1.Template) {
+				if tmpl.Name != "worker-1" {
+					t.Errorf("expected name worker-1, got %q", tmpl.Name)
+				}
+				if tmpl.Spec.Data == nil || !strings.Contains(*tmpl.Spec.Data, "http://image") {
+					t.Errorf("expected rendered data to contain OSImageURL, got %v", tmpl.Spec.Data)
+				}
+			},
+		},
+		{
+			name:    "invalid go template syntax",
+			raw:     `{{.Hostname`,
+			wantErr: true,
+		},
+		{
+			name:    "does not parse as a Template",
+			raw:     `not: [valid, template, } syntax`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := renderTemplateOverride(tt.raw, tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, tmpl)
+		})
+	}
+}
+
+func TestTemplateDataFromHardware(t *testing.T) {
+	hardware := &tinkv1alpha1.Hardware{}
+	hardware.Name = "worker-1"
+	hardware.Spec.Interfaces = []tinkv1alpha1.Interface{
+		{DHCP: &tinkv1alpha1.DHCP{IP: &tinkv1alpha1.IP{Address: "10.0.0.5"}}},
+	}
+
+	data := templateDataFromHardware(hardware, "http://image", "http://hegel")
+
+	if data.Hostname != "worker-1" {
+		t.Errorf("expected hostname worker-1, got %q", data.Hostname)
+	}
+	if data.DeviceIP != "10.0.0.5" {
+		t.Errorf("expected device IP 10.0.0.5, got %q", data.DeviceIP)
+	}
+	if data.OSImageURL != "http://image" || data.HegelURL != "http://hegel" {
+		t.Errorf("unexpected OSImageURL/HegelURL: %+v", data)
+	}
+}
+
+func TestTemplateDataFromHardwareNoInterfaces(t *testing.T) {
+	hardware := &tinkv1alpha1.Hardware{}
+	hardware.Name = "worker-2"
+
+	data := templateDataFromHardware(hardware, "http://image", "http://hegel")
+
+	if data.DeviceIP != "" {
+		t.Errorf("expected empty device IP when hardware has no interfaces, got %q", data.DeviceIP)
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigRecord is one entry in a piece of Hardware's Day-2 config history:
+// one application of a named config template.
+type ConfigRecord struct {
+	ConfigName   string            `json:"configName"`
+	Version      int               `json:"version"`
+	WorkflowName string            `json:"workflowName"`
+	Values       map[string]string `json:"values,omitempty"`
+	AppliedAt    metav1.Time       `json:"appliedAt"`
+}
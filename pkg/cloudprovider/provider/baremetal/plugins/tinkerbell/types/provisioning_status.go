@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Workflow action/workflow states, mirrored from the Tinkerbell Workflow
+// CRD so callers don't need to import tinkv1alpha1 just to compare states.
+const (
+	StatePending = "STATE_PENDING"
+	StateRunning = "STATE_RUNNING"
+	StateSuccess = "STATE_SUCCESS"
+	StateFailed  = "STATE_FAILED"
+)
+
+// ActionStatus is the state of a single action of a single task within a
+// Workflow.
+type ActionStatus struct {
+	TaskName  string
+	Name      string
+	State     string
+	StartedAt *metav1.Time
+	Message   string
+}
+
+// ProvisioningStatus summarizes the Tinkerbell Workflow provisioning a piece
+// of Hardware, so the rest of machine-controller can tell whether
+// provisioning succeeded, is still running, or needs to be retried.
+type ProvisioningStatus struct {
+	WorkflowName string
+	State        string
+	Actions      []ActionStatus
+}
+
+// Failed reports whether the workflow has finished in a failed state. A nil
+// ProvisioningStatus (no workflow observed yet) is never considered failed.
+func (s *ProvisioningStatus) Failed() bool {
+	return s != nil && s.State == StateFailed
+}
+
+// Stale reports whether the workflow is still running but its current
+// action has been running for longer than timeout, meaning it is stuck
+// rather than merely slow. A non-positive timeout disables staleness
+// detection; a nil ProvisioningStatus is never stale.
+func (s *ProvisioningStatus) Stale(timeout time.Duration) bool {
+	if s == nil || timeout <= 0 || s.State != StateRunning {
+		return false
+	}
+
+	for i := len(s.Actions) - 1; i >= 0; i-- {
+		action := s.Actions[i]
+		if action.State != StateRunning {
+			continue
+		}
+		return action.StartedAt != nil && time.Since(action.StartedAt.Time) > timeout
+	}
+
+	return false
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProvisioningStatusStale(t *testing.T) {
+	startedLongAgo := metav1.NewTime(time.Now().Add(-time.Hour))
+	startedRecently := metav1.NewTime(time.Now())
+
+	tests := []struct {
+		name    string
+		status  *ProvisioningStatus
+		timeout time.Duration
+		want    bool
+	}{
+		{
+			name:    "nil status is never stale",
+			status:  nil,
+			timeout: time.Minute,
+			want:    false,
+		},
+		{
+			name:    "non-positive timeout disables staleness detection",
+			status:  &ProvisioningStatus{State: StateRunning, Actions: []ActionStatus{{State: StateRunning, StartedAt: &startedLongAgo}}},
+			timeout: 0,
+			want:    false,
+		},
+		{
+			name:    "not running is never stale",
+			status:  &ProvisioningStatus{State: StateFailed},
+			timeout: time.Minute,
+			want:    false,
+		},
+		{
+			name:    "running action exceeds timeout",
+			status:  &ProvisioningStatus{State: StateRunning, Actions: []ActionStatus{{State: StateRunning, StartedAt: &startedLongAgo}}},
+			timeout: time.Minute,
+			want:    true,
+		},
+		{
+			name:    "running action within timeout",
+			status:  &ProvisioningStatus{State: StateRunning, Actions: []ActionStatus{{State: StateRunning, StartedAt: &startedRecently}}},
+			timeout: time.Minute,
+			want:    false,
+		},
+		{
+			name: "no currently running action",
+			status: &ProvisioningStatus{State: StateRunning, Actions: []ActionStatus{
+				{State: StateSuccess, StartedAt: &startedLongAgo},
+			}},
+			timeout: time.Minute,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.Stale(tt.timeout); got != tt.want {
+				t.Errorf("Stale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioningStatusFailed(t *testing.T) {
+	var nilStatus *ProvisioningStatus
+	if nilStatus.Failed() {
+		t.Error("nil status should never be considered failed")
+	}
+
+	failed := &ProvisioningStatus{State: StateFailed}
+	if !failed.Failed() {
+		t.Error("expected StateFailed status to be Failed()")
+	}
+
+	running := &ProvisioningStatus{State: StateRunning}
+	if running.Failed() {
+		t.Error("expected StateRunning status not to be Failed()")
+	}
+}
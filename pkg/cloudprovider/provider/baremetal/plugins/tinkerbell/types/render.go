@@ -0,0 +1,40 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// RenderGoTemplate executes raw as a Go template against data and returns
+// the rendered text. It backs both TemplateOverride rendering and Day-2
+// config per-instance parameter substitution, so the two stay consistent.
+func RenderGoTemplate(name, raw string, data any) (string, error) {
+	tmpl, err := template.New(name).Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return rendered.String(), nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import "testing"
+
+func TestRenderGoTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		data    any
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "substitutes map values",
+			raw:  "hello {{.name}}",
+			data: map[string]string{"name": "world"},
+			want: "hello world",
+		},
+		{
+			name:    "invalid template syntax",
+			raw:     "hello {{.name",
+			data:    map[string]string{"name": "world"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderGoTemplate(tt.name, tt.raw, tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderGoTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
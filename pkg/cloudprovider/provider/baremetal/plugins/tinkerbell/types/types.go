@@ -0,0 +1,168 @@
+/*
+Copyright 2021 The Machine Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package types holds the Tinkerbell plugin's provider configuration types
+// and the small wrapper types used to satisfy the plugins.Server interface.
+package types
+
+import (
+	providerconfigtypes "github.com/kubermatic/machine-controller/pkg/providerconfig/types"
+
+	tinkv1alpha1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// TinkerbellAuth holds the means to authenticate against the Kubernetes
+// cluster running the Tinkerbell stack.
+type TinkerbellAuth struct {
+	Kubeconfig providerconfigtypes.ConfigVarString `json:"kubeconfig,omitempty"`
+}
+
+// TinkerbellPluginSpec is the user-facing configuration of the Tinkerbell
+// baremetal plugin, embedded in the Machine/MachineDeployment provider spec.
+type TinkerbellPluginSpec struct {
+	Auth        TinkerbellAuth                      `json:"auth,omitempty"`
+	ClusterName providerconfigtypes.ConfigVarString `json:"clusterName,omitempty"`
+	OSImageURL  providerconfigtypes.ConfigVarString `json:"osImageURL,omitempty"`
+	HegelURL    providerconfigtypes.ConfigVarString `json:"hegelURL,omitempty"`
+	HardwareRef types.NamespacedName                `json:"hardwareRef,omitempty"`
+
+	// HardwareSelector, if set, replaces the single Tinkerbell Hardware
+	// referenced by HardwareRef with a pool: ProvisionServer picks any free
+	// Hardware object matching it instead of requiring one Hardware per
+	// MachineDeployment. Takes precedence over HardwareRef, which is then
+	// ignored.
+	HardwareSelector *HardwareSelector `json:"hardwareSelector,omitempty"`
+
+	// TemplateOverride lets users bring their own Tinkerbell provisioning
+	// workflow instead of the one machine-controller would otherwise
+	// generate from OSImageURL/HegelURL. Optional.
+	TemplateOverride TemplateOverride `json:"templateOverride,omitempty"`
+
+	// MetadataServer, if enabled, makes the driver serve Hegel-compatible
+	// instance metadata itself instead of requiring a separately deployed
+	// Hegel. HegelURL is populated automatically when this is enabled.
+	MetadataServer MetadataServerConfig `json:"metadataServer,omitempty"`
+
+	// WorkflowRetryPolicy controls when ProvisionServer deletes and
+	// recreates a Workflow that failed or got stuck instead of leaving the
+	// Machine wedged. With the zero value, only a STATE_FAILED Workflow is
+	// recreated, with no bound on the number of retries.
+	WorkflowRetryPolicy WorkflowRetryPolicy `json:"workflowRetryPolicy,omitempty"`
+}
+
+// WorkflowRetryPolicy configures how ProvisionServer reacts to a Workflow
+// that didn't bring a machine up successfully.
+type WorkflowRetryPolicy struct {
+	// Timeout bounds how long a Workflow's current action may run before
+	// it is considered stuck rather than merely slow. Zero disables this
+	// staleness check, so only a STATE_FAILED workflow triggers a retry.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+
+	// MaxRetries bounds how many times a failed or stuck Workflow for the
+	// same Hardware is deleted and recreated before ProvisionServer gives
+	// up and returns an error instead. Zero means retries are unbounded.
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+// MetadataServerConfig configures the embedded Hegel-compatible metadata
+// server. Requests are authenticated by matching the request's source IP
+// address against the DHCP IP the driver has on record for each piece of
+// Hardware (see metadataclient.Server); there is no MAC-based matching,
+// since that would require reading the network's ARP/neighbor table, which
+// is platform-specific and not something machine-controller has privileged
+// access to from inside its Pod. Deployments must ensure booting machines
+// reach the metadata server from the same address recorded on their
+// Hardware object (i.e. no NAT between the provisioning network and the
+// listener).
+type MetadataServerConfig struct {
+	Enabled       bool                                `json:"enabled,omitempty"`
+	ListenAddress providerconfigtypes.ConfigVarString `json:"listenAddress,omitempty"`
+	TLSCertFile   providerconfigtypes.ConfigVarString `json:"tlsCertFile,omitempty"`
+	TLSKeyFile    providerconfigtypes.ConfigVarString `json:"tlsKeyFile,omitempty"`
+}
+
+// HardwareSelector narrows the pool of Hardware objects a Machine may be
+// provisioned onto: it must match LabelSelector and satisfy every
+// configured resource predicate. Zero-value predicates are not enforced.
+type HardwareSelector struct {
+	LabelSelector metav1.LabelSelector `json:"labelSelector,omitempty"`
+	MinCPU        int                  `json:"minCPU,omitempty"`
+	MinMemoryGB   int                  `json:"minMemoryGB,omitempty"`
+	MinDiskCount  int                  `json:"minDiskCount,omitempty"`
+	MinDiskSizeGB int                  `json:"minDiskSizeGB,omitempty"`
+	NICMACPrefix  string               `json:"nicMACPrefix,omitempty"`
+}
+
+// TemplateOverride describes a user-supplied Tinkerbell Template to use for
+// provisioning instead of the built-in one. Exactly one of Raw or Ref
+// should be set; Ref takes precedence if both are.
+type TemplateOverride struct {
+	// Raw is a literal Tinkerbell Template manifest (YAML). It is rendered
+	// as a Go template before use, with access to .OSImageURL, .HegelURL,
+	// .Hostname and .DeviceIP resolved from the target Hardware object.
+	Raw string `json:"raw,omitempty"`
+
+	// Ref points at an existing tinkv1alpha1.Template object to use
+	// verbatim, without substitution.
+	Ref *types.NamespacedName `json:"ref,omitempty"`
+}
+
+// IsSet reports whether the user configured a template override.
+func (t TemplateOverride) IsSet() bool {
+	return t.Raw != "" || t.Ref != nil
+}
+
+// TemplateData is the set of values resolved from a Hardware object that
+// are made available when rendering a Raw TemplateOverride.
+type TemplateData struct {
+	OSImageURL string
+	HegelURL   string
+	Hostname   string
+	DeviceIP   string
+	Disk       string
+}
+
+// Config is the resolved, ready-to-use configuration built from a
+// TinkerbellPluginSpec by GetConfig.
+type Config struct {
+	Kubeconfig  string
+	ClusterName string
+	OSImageURL  string
+	HegelURL    string
+	RestConfig  *rest.Config
+}
+
+// Hardware adapts a tinkv1alpha1.Hardware object to the plugins.Server
+// interface expected by the baremetal cloud provider.
+type Hardware struct {
+	*tinkv1alpha1.Hardware
+
+	// ProvisioningStatus reflects the state of the Workflow provisioning
+	// this Hardware, if one has been created for it yet.
+	ProvisioningStatus *ProvisioningStatus
+
+	// ConfigHistory lists the Day-2 configs applied to this Hardware so
+	// far, oldest first.
+	ConfigHistory []ConfigRecord
+}
+
+// Name returns the name of the underlying Hardware object.
+func (h *Hardware) Name() string {
+	return h.Hardware.Name
+}